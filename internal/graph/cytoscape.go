@@ -0,0 +1,163 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/loov/goda/internal/pkggraph"
+	"github.com/loov/goda/internal/pkgtree"
+)
+
+// Cytoscape renders a graph as Cytoscape.js elements JSON, using each
+// node's "parent" field to express cluster nesting.
+type Cytoscape struct {
+	out io.Writer
+	err io.Writer
+
+	opts Options
+}
+
+// NewCytoscape creates a Cytoscape Writer.
+func NewCytoscape(out, err io.Writer, opts Options) *Cytoscape {
+	return &Cytoscape{out: out, err: err, opts: opts}
+}
+
+type cytoscapeNodeData struct {
+	ID     string `json:"id"`
+	Label  string `json:"label"`
+	Href   string `json:"href,omitempty"`
+	Parent string `json:"parent,omitempty"`
+}
+
+type cytoscapeEdgeData struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type cytoscapeElement struct {
+	Data interface{} `json:"data"`
+}
+
+type cytoscapeDoc struct {
+	Elements struct {
+		Nodes []cytoscapeElement `json:"nodes"`
+		Edges []cytoscapeElement `json:"edges"`
+	} `json:"elements"`
+}
+
+func (ctx *Cytoscape) label(n *pkggraph.Node) string {
+	var labelText strings.Builder
+	if err := ctx.opts.Label.Execute(&labelText, n); err != nil {
+		fmt.Fprintf(ctx.err, "template error: %v\n", err)
+	}
+	return labelText.String()
+}
+
+func (ctx *Cytoscape) Write(graph *pkggraph.Graph) error {
+	var doc cytoscapeDoc
+
+	addNode := func(n *pkggraph.Node, parent string) {
+		doc.Elements.Nodes = append(doc.Elements.Nodes, cytoscapeElement{
+			Data: cytoscapeNodeData{
+				ID:     safeID(n.ID),
+				Label:  ctx.label(n),
+				Href:   ctx.opts.Docs + n.ID,
+				Parent: parent,
+			},
+		})
+	}
+	addCluster := func(id, label, parent string) {
+		doc.Elements.Nodes = append(doc.Elements.Nodes, cytoscapeElement{
+			Data: cytoscapeNodeData{ID: id, Label: label, Parent: parent},
+		})
+	}
+
+	switch {
+	case ctx.opts.Clusters && ctx.opts.ClusterByDir:
+		root, err := pkgtree.From(graph)
+		if err != nil {
+			return fmt.Errorf("failed to construct cluster tree: %v", err)
+		}
+		basePackage := pkgtree.GetBasePackage(graph)
+		dirRoot := pkgtree.ClusterByDirectory(root, basePackage, ctx.opts.ClusterDepth)
+
+		for _, pkg := range dirRoot.Packages {
+			addNode(pkg.GraphNode, "")
+		}
+		var walk func(dc *pkgtree.DirCluster, parent string)
+		walk = func(dc *pkgtree.DirCluster, parent string) {
+			id := safeID("dir:" + dc.Path)
+			displayName := dc.Path
+			if idx := strings.LastIndex(dc.Path, "/"); idx >= 0 {
+				displayName = dc.Path[idx+1:]
+			}
+			addCluster(id, displayName, parent)
+			for _, pkg := range dc.Packages {
+				addNode(pkg.GraphNode, id)
+			}
+			for _, child := range dc.Children {
+				walk(child, id)
+			}
+		}
+		for _, child := range dirRoot.Children {
+			walk(child, "")
+		}
+
+	case ctx.opts.Clusters:
+		root, err := pkgtree.From(graph)
+		if err != nil {
+			return fmt.Errorf("failed to construct cluster tree: %v", err)
+		}
+
+		var visit func(tn pkgtree.Node, parent string)
+		visit = func(tn pkgtree.Node, parent string) {
+			switch tn := tn.(type) {
+			case *pkgtree.Repo:
+				if tn.SameAsOnlyModule() {
+					tn.VisitChildren(func(c pkgtree.Node) { visit(c, parent) })
+					return
+				}
+				id := safeID("repo:" + tn.Path())
+				addCluster(id, tn.Path(), parent)
+				tn.VisitChildren(func(c pkgtree.Node) { visit(c, id) })
+				return
+
+			case *pkgtree.Module:
+				id := safeID("mod:" + tn.Path())
+				addCluster(id, moduleLabel(tn), parent)
+				tn.VisitChildren(func(c pkgtree.Node) { visit(c, id) })
+				return
+
+			case *pkgtree.Package:
+				addNode(tn.GraphNode, parent)
+				tn.VisitChildren(func(c pkgtree.Node) { visit(c, parent) })
+				return
+			}
+		}
+		root.VisitChildren(func(c pkgtree.Node) { visit(c, "") })
+
+	default:
+		for _, n := range graph.Sorted {
+			addNode(n, "")
+		}
+	}
+
+	for _, src := range graph.Sorted {
+		for _, dst := range src.ImportsNodes {
+			doc.Elements.Edges = append(doc.Elements.Edges, cytoscapeElement{
+				Data: cytoscapeEdgeData{
+					ID:     safeID(src.ID) + "->" + safeID(dst.ID),
+					Source: safeID(src.ID),
+					Target: safeID(dst.ID),
+				},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(ctx.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
@@ -23,6 +24,12 @@ type Dot struct {
 	clusterColors bool
 	nocolor       bool
 	shortID       bool
+	legend        bool
+
+	collapse pkgtree.CollapsePredicate
+
+	highlightCycles bool
+	condenseCycles  bool
 
 	label *template.Template
 }
@@ -48,6 +55,28 @@ var clusterBorderColors = []string{
 	"#154360", // Very deep
 }
 
+// escapeDot escapes s for use inside a double-quoted DOT attribute value.
+// Backslashes and quotes are escaped so Graphviz doesn't choke on package
+// paths, replace directives, or user-supplied label templates, and newlines
+// are turned into "\l" so multi-line labels stay left-justified.
+func escapeDot(s string) string {
+	var out strings.Builder
+	out.Grow(len(s))
+	for _, r := range s {
+		switch r {
+		case '\\':
+			out.WriteString(`\\`)
+		case '"':
+			out.WriteString(`\"`)
+		case '\n':
+			out.WriteString(`\l`)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
 func (ctx *Dot) Label(p *pkggraph.Node) string {
 	var labelText strings.Builder
 	err := ctx.label.Execute(&labelText, p)
@@ -58,20 +87,7 @@ func (ctx *Dot) Label(p *pkggraph.Node) string {
 }
 
 func (ctx *Dot) ModuleLabel(mod *pkgtree.Module) string {
-	lbl := mod.Mod.Path
-	if mod.Mod.Version != "" {
-		lbl += "@" + mod.Mod.Version
-	}
-	if mod.Local {
-		lbl += " (local)"
-	}
-	if rep := mod.Mod.Replace; rep != nil {
-		lbl += " =>\\n" + rep.Path
-		if rep.Version != "" {
-			lbl += "@" + rep.Version
-		}
-	}
-	return lbl
+	return moduleLabel(mod)
 }
 
 func (ctx *Dot) TreePackageLabel(tp *pkgtree.Package, parentPrinted bool) string {
@@ -95,19 +111,19 @@ func (ctx *Dot) TreePackageLabel(tp *pkgtree.Package, parentPrinted bool) string
 }
 
 func (ctx *Dot) RepoRef(repo *pkgtree.Repo) string {
-	return fmt.Sprintf(`href=%q`, ctx.docs+repo.Path())
+	return fmt.Sprintf("href=\"%v\"", escapeDot(ctx.docs+repo.Path()))
 }
 
 func (ctx *Dot) ModuleRef(mod *pkgtree.Module) string {
-	return fmt.Sprintf(`href=%q`, ctx.docs+mod.Path()+"@"+mod.Mod.Version)
+	return fmt.Sprintf("href=\"%v\"", escapeDot(ctx.docs+mod.Path()+"@"+mod.Mod.Version))
 }
 
 func (ctx *Dot) TreePackageRef(tp *pkgtree.Package) string {
-	return fmt.Sprintf(`href=%q`, ctx.docs+tp.Path())
+	return fmt.Sprintf("href=\"%v\"", escapeDot(ctx.docs+tp.Path()))
 }
 
 func (ctx *Dot) Ref(p *pkggraph.Node) string {
-	return fmt.Sprintf(`href=%q`, ctx.docs+p.ID)
+	return fmt.Sprintf("href=\"%v\"", escapeDot(ctx.docs+p.ID))
 }
 
 // getClusterColorsForDepth returns background and border colors for a cluster at given depth
@@ -136,6 +152,61 @@ func (ctx *Dot) writeGraphProperties() {
 	fmt.Fprintf(ctx.out, "    quantum=\"0.5\";\n")
 }
 
+// writeLegend emits a single cluster_L subgraph describing the color and
+// shape conventions used elsewhere in the graph. It must be called at
+// most once, after the rest of the graph body, so it sits outside the
+// compound cluster tree and doesn't perturb rankdir=LR layout.
+func (ctx *Dot) writeLegend(graph *pkggraph.Graph, depthsUsed []int) {
+	if !ctx.legend {
+		return
+	}
+
+	var lines []string
+
+	seenDepth := map[int]bool{}
+	var depths []int
+	for _, depth := range depthsUsed {
+		if !seenDepth[depth] {
+			seenDepth[depth] = true
+			depths = append(depths, depth)
+		}
+	}
+	sort.Ints(depths)
+	for _, depth := range depths {
+		bg, border := ctx.getClusterColorsForDepth(depth)
+		if bg == "" && border == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("cluster depth %d: fill %s, border %s", depth, bg, border))
+	}
+
+	lines = append(lines, "node color: sha256(package path) -> hue")
+	for i := 0; i < len(graph.Sorted) && i < 2; i++ {
+		p := graph.Sorted[i]
+		hash := sha256.Sum256([]byte(p.PkgPath))
+		hue := float64(uint(hash[0])<<8|uint(hash[1])) / 0xFFFF
+		lines = append(lines, fmt.Sprintf("  %s -> %s", p.PkgPath, hslahex(hue, 0.9, 0.3, 0.7)))
+	}
+
+	lines = append(lines, "circle = cluster root package (path == directory path)")
+	lines = append(lines, "point = cluster root with a single child package")
+
+	if ctx.docs != "" {
+		lines = append(lines, "docs base: "+ctx.docs)
+	}
+
+	label := escapeDot(strings.Join(lines, "\n"))
+
+	fmt.Fprintf(ctx.out, "    subgraph cluster_L {\n")
+	fmt.Fprintf(ctx.out, "        style=invis;\n")
+	fmt.Fprintf(ctx.out, "        legend_box [shape=box style=filled fillcolor=white label=\"%s\"", label)
+	if ctx.docs != "" {
+		fmt.Fprintf(ctx.out, " href=\"%s\"", escapeDot(ctx.docs))
+	}
+	fmt.Fprintf(ctx.out, "];\n")
+	fmt.Fprintf(ctx.out, "    }\n")
+}
+
 func (ctx *Dot) Write(graph *pkggraph.Graph) error {
 	if ctx.clusters {
 		if ctx.clusterByDir {
@@ -152,19 +223,96 @@ func (ctx *Dot) WriteRegular(graph *pkggraph.Graph) error {
 	ctx.writeGraphProperties()
 	defer fmt.Fprintf(ctx.out, "}\n")
 
-	for _, n := range graph.Sorted {
-		fmt.Fprintf(ctx.out, "    %v [label=\"%v\" %v %v];\n", pkgID(n), ctx.Label(n), ctx.Ref(n), ctx.colorOf(n))
+	var sccOf map[*pkggraph.Node]int
+	var sccMembers [][]*pkggraph.Node
+	if ctx.highlightCycles || ctx.condenseCycles {
+		sccOf, sccMembers = nontrivialSCCs(graph)
 	}
 
-	for _, src := range graph.Sorted {
-		for _, dst := range src.ImportsNodes {
-			fmt.Fprintf(ctx.out, "    %v -> %v [%v];\n", pkgID(src), pkgID(dst), ctx.colorOf(dst))
+	if ctx.condenseCycles {
+		for _, n := range graph.Sorted {
+			if _, condensed := sccOf[n]; condensed {
+				continue
+			}
+			fmt.Fprintf(ctx.out, "    %v [label=\"%v\" %v %v];\n", pkgID(n), escapeDot(ctx.Label(n)), ctx.Ref(n), ctx.colorOf(n))
+		}
+		for id, members := range sccMembers {
+			fmt.Fprintf(ctx.out, "    %v [shape=rectangle label=\"%v\" tooltip=\"%v\" color=\"red\" penwidth=3];\n",
+				sccNodeID(id), escapeDot(sccLabel(members)), escapeDot(sccTooltip(members)))
+		}
+
+		seenEdge := map[[2]string]bool{}
+		for _, src := range graph.Sorted {
+			srcID := pkgID(src)
+			if id, condensed := sccOf[src]; condensed {
+				srcID = sccNodeID(id)
+			}
+			for _, dst := range src.ImportsNodes {
+				dstID := pkgID(dst)
+				if id, condensed := sccOf[dst]; condensed {
+					dstID = sccNodeID(id)
+				}
+				if srcID == dstID {
+					continue
+				}
+				key := [2]string{srcID, dstID}
+				if seenEdge[key] {
+					continue
+				}
+				seenEdge[key] = true
+				fmt.Fprintf(ctx.out, "    %v -> %v [%v];\n", srcID, dstID, ctx.colorOf(dst))
+			}
+		}
+	} else {
+		for _, n := range graph.Sorted {
+			fmt.Fprintf(ctx.out, "    %v [label=\"%v\" %v %v];\n", pkgID(n), escapeDot(ctx.Label(n)), ctx.Ref(n), ctx.colorOf(n))
+		}
+
+		if ctx.highlightCycles {
+			ctx.writeSCCClusters(sccMembers)
+		}
+
+		for _, src := range graph.Sorted {
+			for _, dst := range src.ImportsNodes {
+				fmt.Fprintf(ctx.out, "    %v -> %v [%v];\n", pkgID(src), pkgID(dst), ctx.cycleEdgeAttrs(sccOf, src, dst))
+			}
 		}
 	}
 
+	ctx.writeLegend(graph, nil)
+
 	return nil
 }
 
+// cycleEdgeAttrs returns the DOT color attribute for an edge: red and
+// heavier when --highlight-cycles is set and both endpoints are in the same
+// nontrivial SCC, otherwise the usual per-destination color.
+func (ctx *Dot) cycleEdgeAttrs(sccOf map[*pkggraph.Node]int, src, dst *pkggraph.Node) string {
+	if ctx.highlightCycles {
+		if srcSCC, ok := sccOf[src]; ok {
+			if dstSCC, ok := sccOf[dst]; ok && dstSCC == srcSCC {
+				return `color="red" penwidth=3`
+			}
+		}
+	}
+	return ctx.colorOf(dst)
+}
+
+// writeSCCClusters wraps each nontrivial SCC's members in a red-bordered
+// cluster_scc_N subgraph, for --highlight-cycles in ungrouped (non-cluster)
+// graphs, where every member can share a single enclosing subgraph.
+func (ctx *Dot) writeSCCClusters(sccMembers [][]*pkggraph.Node) {
+	for id, members := range sccMembers {
+		fmt.Fprintf(ctx.out, "    subgraph %q {\n", sccClusterName(id))
+		fmt.Fprintf(ctx.out, "        color=\"red\"; penwidth=3;\n")
+		fmt.Fprintf(ctx.out, "        label=\"%v\"; tooltip=\"%v\";\n", escapeDot(sccLabel(members)), escapeDot(sccTooltip(members)))
+		for _, n := range members {
+			fmt.Fprintf(ctx.out, "        %v;\n", pkgID(n))
+		}
+		fmt.Fprintf(ctx.out, "    }\n")
+	}
+}
+
 func (ctx *Dot) WriteClusters(graph *pkggraph.Graph) error {
 	root, err := pkgtree.From(graph)
 	if err != nil {
@@ -173,6 +321,12 @@ func (ctx *Dot) WriteClusters(graph *pkggraph.Graph) error {
 	lookup := root.LookupTable()
 	isCluster := map[*pkggraph.Node]bool{}
 
+	var sccOf map[*pkggraph.Node]int
+	var sccMembers [][]*pkggraph.Node
+	if ctx.highlightCycles || ctx.condenseCycles {
+		sccOf, sccMembers = nontrivialSCCs(graph)
+	}
+
 	fmt.Fprintf(ctx.out, "digraph G {\n")
 	ctx.writeGraphProperties()
 	defer fmt.Fprintf(ctx.out, "}\n")
@@ -188,8 +342,8 @@ func (ctx *Dot) WriteClusters(graph *pkggraph.Graph) error {
 			}
 			printed[tn] = true
 			fmt.Fprintf(ctx.out, "subgraph %q {\n", "cluster_"+tn.Path())
-			fmt.Fprintf(ctx.out, "    label=\"%v\"\n", tn.Path())
-			fmt.Fprintf(ctx.out, "    tooltip=\"%v\"\n", tn.Path())
+			fmt.Fprintf(ctx.out, "    label=\"%v\"\n", escapeDot(tn.Path()))
+			fmt.Fprintf(ctx.out, "    tooltip=\"%v\"\n", escapeDot(tn.Path()))
 			fmt.Fprintf(ctx.out, "    %v\n", ctx.RepoRef(tn))
 			defer fmt.Fprintf(ctx.out, "}\n")
 
@@ -197,25 +351,28 @@ func (ctx *Dot) WriteClusters(graph *pkggraph.Graph) error {
 			printed[tn] = true
 			label := ctx.ModuleLabel(tn)
 			fmt.Fprintf(ctx.out, "subgraph %q {\n", "cluster_"+tn.Path())
-			fmt.Fprintf(ctx.out, "    label=\"%v\"\n", label)
-			fmt.Fprintf(ctx.out, "    tooltip=\"%v\"\n", label)
+			fmt.Fprintf(ctx.out, "    label=\"%v\"\n", escapeDot(label))
+			fmt.Fprintf(ctx.out, "    tooltip=\"%v\"\n", escapeDot(label))
 			fmt.Fprintf(ctx.out, "    %v\n", ctx.ModuleRef(tn))
 			defer fmt.Fprintf(ctx.out, "}\n")
 
 		case *pkgtree.Package:
 			printed[tn] = true
 			gn := tn.GraphNode
+			if _, condensed := sccOf[gn]; condensed && ctx.condenseCycles {
+				break
+			}
 			if tn.Path() == tn.Parent.Path() {
 				isCluster[tn.GraphNode] = true
 				shape := "circle"
 				if tn.OnlyChild() {
 					shape = "point"
 				}
-				fmt.Fprintf(ctx.out, "    %v [label=\"\" tooltip=\"%v\" shape=%v %v rank=0];\n", pkgID(gn), tn.Path(), shape, ctx.colorOf(gn))
+				fmt.Fprintf(ctx.out, "    %v [label=\"\" tooltip=\"%v\" shape=%v %v rank=0];\n", pkgID(gn), escapeDot(tn.Path()), shape, ctx.colorOf(gn))
 			} else {
 				label := ctx.TreePackageLabel(tn, printed[tn.Parent])
 				href := ctx.TreePackageRef(tn)
-				fmt.Fprintf(ctx.out, "    %v [label=\"%v\" tooltip=\"%v\" %v %v];\n", pkgID(gn), label, tn.Path(), href, ctx.colorOf(gn))
+				fmt.Fprintf(ctx.out, "    %v [label=\"%v\" tooltip=\"%v\" %v %v];\n", pkgID(gn), escapeDot(label), escapeDot(tn.Path()), href, ctx.colorOf(gn))
 			}
 		}
 
@@ -223,24 +380,85 @@ func (ctx *Dot) WriteClusters(graph *pkggraph.Graph) error {
 	}
 	root.VisitChildren(visit)
 
+	if ctx.highlightCycles {
+		ctx.writeSCCClustersByParent(sccMembers, lookup)
+	}
+
+	if ctx.condenseCycles {
+		for id, members := range sccMembers {
+			fmt.Fprintf(ctx.out, "    %v [shape=rectangle label=\"%v\" tooltip=\"%v\" color=\"red\" penwidth=3];\n",
+				sccNodeID(id), escapeDot(sccLabel(members)), escapeDot(sccTooltip(members)))
+		}
+	}
+
+	seenEdge := map[[2]string]bool{}
 	for _, src := range graph.Sorted {
 		srctree := lookup[src]
+		srcID := pkgID(src)
+		if id, condensed := sccOf[src]; condensed && ctx.condenseCycles {
+			srcID = sccNodeID(id)
+		}
 		for _, dst := range src.ImportsNodes {
 			dstID := pkgID(dst)
 			dstTree := lookup[dst]
-			tooltip := src.ID + " -> " + dst.ID
+			dstCondensed := false
+			if id, condensed := sccOf[dst]; condensed && ctx.condenseCycles {
+				dstID = sccNodeID(id)
+				dstCondensed = true
+			}
+			if srcID == dstID {
+				continue
+			}
+			key := [2]string{srcID, dstID}
+			if seenEdge[key] {
+				continue
+			}
+			seenEdge[key] = true
+
+			tooltip := escapeDot(src.ID + " -> " + dst.ID)
+			color := ctx.cycleEdgeAttrs(sccOf, src, dst)
 
-			if isCluster[dst] && srctree.Parent != dstTree {
-				fmt.Fprintf(ctx.out, "    %v -> %v [tooltip=\"%v\" lhead=%q %v];\n", pkgID(src), dstID, tooltip, "cluster_"+dst.ID, ctx.colorOf(dst))
+			if !dstCondensed && isCluster[dst] && srctree.Parent != dstTree {
+				fmt.Fprintf(ctx.out, "    %v -> %v [tooltip=\"%v\" lhead=%q %v];\n", srcID, dstID, tooltip, "cluster_"+dst.ID, color)
 			} else {
-				fmt.Fprintf(ctx.out, "    %v -> %v [tooltip=\"%v\" %v];\n", pkgID(src), dstID, tooltip, ctx.colorOf(dst))
+				fmt.Fprintf(ctx.out, "    %v -> %v [tooltip=\"%v\" %v];\n", srcID, dstID, tooltip, color)
 			}
 		}
 	}
 
+	ctx.writeLegend(graph, nil)
+
 	return nil
 }
 
+// writeSCCClustersByParent wraps a nontrivial SCC's members in a
+// cluster_scc_N subgraph only when every member shares the same parent
+// tree node -- otherwise the cycle spans clusters and is left to the red
+// edge coloring alone, per cycleEdgeAttrs.
+func (ctx *Dot) writeSCCClustersByParent(sccMembers [][]*pkggraph.Node, lookup map[*pkggraph.Node]pkgtree.Node) {
+	for id, members := range sccMembers {
+		parent := lookup[members[0]].Parent
+		samesParent := true
+		for _, n := range members[1:] {
+			if lookup[n].Parent != parent {
+				samesParent = false
+				break
+			}
+		}
+		if !samesParent {
+			continue
+		}
+
+		fmt.Fprintf(ctx.out, "subgraph %q {\n", sccClusterName(id))
+		fmt.Fprintf(ctx.out, "    color=\"red\"; penwidth=3;\n")
+		fmt.Fprintf(ctx.out, "    label=\"%v\"; tooltip=\"%v\";\n", escapeDot(sccLabel(members)), escapeDot(sccTooltip(members)))
+		for _, n := range members {
+			fmt.Fprintf(ctx.out, "    %v;\n", pkgID(n))
+		}
+		fmt.Fprintf(ctx.out, "}\n")
+	}
+}
+
 func (ctx *Dot) colorOf(p *pkggraph.Node) string {
 	if p.Color != "" {
 		return "color=" + strconv.Quote(p.Color)
@@ -278,35 +496,183 @@ func (ctx *Dot) WriteDirectoryClusters(graph *pkggraph.Graph) error {
 			gn := pkg.GraphNode
 			label := ctx.Label(gn)
 			href := ctx.Ref(gn)
-			fmt.Fprintf(ctx.out, "    %v [label=\"%v\" %v %v];\n", pkgID(gn), label, href, ctx.colorOf(gn))
+			fmt.Fprintf(ctx.out, "    %v [label=\"%v\" %v %v];\n", pkgID(gn), escapeDot(label), href, ctx.colorOf(gn))
 		}
 		fmt.Fprintf(ctx.out, "\n")
 	}
 
+	// Collapse subtrees that are fully matched by --collapse* before
+	// writing, so hidden packages are replaced by one summary node per
+	// frontier cluster and their edges are rewired onto it.
+	hiddenOwner := map[string]*pkgtree.DirCluster{}
+	if ctx.collapse != nil {
+		for _, frontier := range pkgtree.CollapseFrontier(dirRoot, ctx.collapse) {
+			for _, id := range frontier.Summary.PackageIDs {
+				hiddenOwner[id] = frontier
+			}
+		}
+	}
+
+	var sccOf map[*pkggraph.Node]int
+	var sccMembers [][]*pkggraph.Node
+	if ctx.highlightCycles || ctx.condenseCycles {
+		sccOf, sccMembers = nontrivialSCCs(graph)
+	}
+
+	// When --condense-cycles collapses a cycle into one node, its members
+	// must be hidden from the normal per-cluster package writing, the same
+	// way a collapsed directory subtree hides its packages.
+	hiddenBySCC := map[string]int{}
+	if ctx.condenseCycles {
+		for id, members := range sccMembers {
+			for _, n := range members {
+				hiddenBySCC[n.ID] = id
+			}
+		}
+	}
+
 	// Write directory clusters recursively
 	for _, child := range dirRoot.Children {
-		ctx.writeDirCluster(child, "    ")
+		ctx.writeDirCluster(child, "    ", hiddenBySCC)
+	}
+
+	if ctx.condenseCycles && len(sccMembers) > 0 {
+		fmt.Fprintf(ctx.out, "    // Condensed cycles\n")
+		for id, members := range sccMembers {
+			fmt.Fprintf(ctx.out, "    %v [shape=rectangle label=\"%v\" tooltip=\"%v\" color=\"red\" penwidth=3];\n",
+				sccNodeID(id), escapeDot(sccLabel(members)), escapeDot(sccTooltip(members)))
+		}
 	}
 
-	// Write edges
+	// clusterOwners maps a package ID to the DirCluster whose subgraph
+	// directly contains it, so a cycle edge that crosses directory clusters
+	// can point lhead/ltail at the right subgraph instead of the node.
+	var clusterOwners map[string]*pkgtree.DirCluster
+	if ctx.highlightCycles {
+		clusterOwners = map[string]*pkgtree.DirCluster{}
+		for _, child := range dirRoot.Children {
+			collectDirClusterOwners(child, clusterOwners)
+		}
+	}
+
+	// Write edges, rewriting any endpoint inside a collapsed subtree or a
+	// condensed cycle onto its summary/cycle node and deduplicating the
+	// resulting edges.
 	fmt.Fprintf(ctx.out, "    // Edges\n")
+	seenEdge := map[[2]string]bool{}
 	for _, src := range graph.Sorted {
+		srcID := pkgID(src)
+		if owner, ok := hiddenOwner[src.ID]; ok {
+			srcID = collapsedNodeID(owner.Path)
+		} else if id, ok := hiddenBySCC[src.ID]; ok {
+			srcID = sccNodeID(id)
+		}
 		for _, dst := range src.ImportsNodes {
-			tooltip := src.ID + " -> " + dst.ID
-			fmt.Fprintf(ctx.out, "    %v -> %v [tooltip=\"%v\" %v];\n", pkgID(src), pkgID(dst), tooltip, ctx.colorOf(dst))
+			dstID := pkgID(dst)
+			if owner, ok := hiddenOwner[dst.ID]; ok {
+				dstID = collapsedNodeID(owner.Path)
+			} else if id, ok := hiddenBySCC[dst.ID]; ok {
+				dstID = sccNodeID(id)
+			}
+			if srcID == dstID {
+				continue
+			}
+			key := [2]string{srcID, dstID}
+			if seenEdge[key] {
+				continue
+			}
+			seenEdge[key] = true
+
+			tooltip := escapeDot(src.ID + " -> " + dst.ID)
+			color := ctx.colorOf(dst)
+			crossClusterAttrs := ""
+			if ctx.highlightCycles {
+				color = ctx.cycleEdgeAttrs(sccOf, src, dst)
+				if srcSCC, ok := sccOf[src]; ok {
+					if dstSCC, ok := sccOf[dst]; ok && dstSCC == srcSCC {
+						if srcCluster, dstCluster := clusterOwners[src.ID], clusterOwners[dst.ID]; srcCluster != dstCluster {
+							if srcCluster != nil {
+								crossClusterAttrs += fmt.Sprintf(" ltail=%q", dirClusterDotName(srcCluster.Path))
+							}
+							if dstCluster != nil {
+								crossClusterAttrs += fmt.Sprintf(" lhead=%q", dirClusterDotName(dstCluster.Path))
+							}
+						}
+					}
+				}
+			}
+			fmt.Fprintf(ctx.out, "    %v -> %v [tooltip=\"%v\" %v%v];\n", srcID, dstID, tooltip, color, crossClusterAttrs)
 		}
 	}
 
+	var depthsUsed []int
+	for _, child := range dirRoot.Children {
+		collectDirClusterDepths(child, &depthsUsed)
+	}
+	ctx.writeLegend(graph, depthsUsed)
+
 	return nil
 }
 
-// writeDirCluster writes a directory cluster and its nested subclusters
-func (ctx *Dot) writeDirCluster(dc *pkgtree.DirCluster, indent string) {
+// collectDirClusterDepths gathers the depth (matching the argument
+// ctx.getClusterColorsForDepth was called with) of every DirCluster in dc's
+// subtree, for use in the legend. A collapsed subtree (dc.Summary != nil)
+// is written by writeDirCluster as a single rectangle with no depth color
+// at all, and writeDirCluster doesn't recurse into its children either, so
+// neither dc's own depth nor its children's depths are actually used in the
+// output and must not be collected.
+func collectDirClusterDepths(dc *pkgtree.DirCluster, depths *[]int) {
+	if dc.Summary != nil {
+		return
+	}
+	*depths = append(*depths, dc.Depth-1)
+	for _, child := range dc.Children {
+		collectDirClusterDepths(child, depths)
+	}
+}
+
+// collapsedNodeID derives the DOT node id for the summary node standing in
+// for a collapsed directory subtree rooted at path.
+func collapsedNodeID(path string) string {
+	return "collapsed_" + strings.ReplaceAll(strings.ReplaceAll(path, "/", "_"), ".", "_")
+}
+
+// dirClusterDotName derives the DOT subgraph name that writeDirCluster gives
+// the cluster of the directory at path.
+func dirClusterDotName(path string) string {
+	return "cluster_" + strings.ReplaceAll(strings.ReplaceAll(path, "/", "_"), ".", "_")
+}
+
+// collectDirClusterOwners records, for every package under dc, the DirCluster
+// whose subgraph directly contains it.
+func collectDirClusterOwners(dc *pkgtree.DirCluster, owners map[string]*pkgtree.DirCluster) {
+	for _, pkg := range dc.Packages {
+		owners[pkg.GraphNode.ID] = dc
+	}
+	for _, child := range dc.Children {
+		collectDirClusterOwners(child, owners)
+	}
+}
+
+// writeDirCluster writes a directory cluster and its nested subclusters. If
+// dc.Summary is set (CollapseFrontier collapsed this subtree), it instead
+// writes a single summary node and doesn't recurse into dc's children.
+// hiddenBySCC holds package IDs that --condense-cycles collapsed into a
+// cycle node elsewhere, so this skips writing them here.
+func (ctx *Dot) writeDirCluster(dc *pkgtree.DirCluster, indent string, hiddenBySCC map[string]int) {
+	if dc.Summary != nil {
+		label := fmt.Sprintf("%s/... (%d pkgs)", dc.Path, dc.Summary.Count)
+		tooltip := strings.Join(dc.Summary.PackageIDs, "\n")
+		fmt.Fprintf(ctx.out, "%s%s [shape=rectangle label=\"%s\" tooltip=\"%s\" href=\"%s\"];\n",
+			indent, collapsedNodeID(dc.Path), escapeDot(label), escapeDot(tooltip), escapeDot(ctx.docs+dc.Path))
+		return
+	}
+
 	// Get colors based on depth
 	bgColor, borderColor := ctx.getClusterColorsForDepth(dc.Depth - 1)
 
 	// Create cluster name from path
-	clusterName := "cluster_" + strings.ReplaceAll(strings.ReplaceAll(dc.Path, "/", "_"), ".", "_")
+	clusterName := dirClusterDotName(dc.Path)
 
 	// Get the display name (last component of path)
 	displayName := dc.Path
@@ -315,7 +681,7 @@ func (ctx *Dot) writeDirCluster(dc *pkgtree.DirCluster, indent string) {
 	}
 
 	fmt.Fprintf(ctx.out, "%ssubgraph %q {\n", indent, clusterName)
-	fmt.Fprintf(ctx.out, "%s    label=\"%s\";\n", indent, displayName)
+	fmt.Fprintf(ctx.out, "%s    label=\"%s\";\n", indent, escapeDot(displayName))
 	fmt.Fprintf(ctx.out, "%s    style=filled;\n", indent)
 
 	if bgColor != "" {
@@ -347,13 +713,17 @@ func (ctx *Dot) writeDirCluster(dc *pkgtree.DirCluster, indent string) {
 
 	fmt.Fprintf(ctx.out, "\n")
 
-	// Write packages directly in this cluster
+	// Write packages directly in this cluster, skipping any that
+	// --condense-cycles collapsed into a cycle node written elsewhere.
 	if len(dc.Packages) > 0 {
 		for _, pkg := range dc.Packages {
 			gn := pkg.GraphNode
+			if _, condensed := hiddenBySCC[gn.ID]; condensed {
+				continue
+			}
 			label := ctx.Label(gn)
 			href := ctx.Ref(gn)
-			fmt.Fprintf(ctx.out, "%s    %v [label=\"%v\" %v %v];\n", indent, pkgID(gn), label, href, ctx.colorOf(gn))
+			fmt.Fprintf(ctx.out, "%s    %v [label=\"%v\" %v %v];\n", indent, pkgID(gn), escapeDot(label), href, ctx.colorOf(gn))
 		}
 		if len(dc.Children) > 0 {
 			fmt.Fprintf(ctx.out, "\n")
@@ -362,7 +732,7 @@ func (ctx *Dot) writeDirCluster(dc *pkgtree.DirCluster, indent string) {
 
 	// Write nested subclusters
 	for _, child := range dc.Children {
-		ctx.writeDirCluster(child, indent+"    ")
+		ctx.writeDirCluster(child, indent+"    ", hiddenBySCC)
 	}
 
 	fmt.Fprintf(ctx.out, "%s}\n\n", indent)
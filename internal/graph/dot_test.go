@@ -0,0 +1,238 @@
+package graph
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/loov/goda/internal/pkggraph"
+	"github.com/loov/goda/internal/pkgtree"
+)
+
+func TestEscapeDot(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{``, ``},
+		{`plain`, `plain`},
+		{`has "quotes"`, `has \"quotes\"`},
+		{`C:\path\to\pkg`, `C:\\path\\to\\pkg`},
+		{"line1\nline2", `line1\lline2`},
+		{`mix "a\b"` + "\nend", `mix \"a\\b\"\lend`},
+	}
+
+	for _, test := range tests {
+		got := escapeDot(test.input)
+		if got != test.want {
+			t.Errorf("escapeDot(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func newTestDot(out io.Writer) *Dot {
+	return &Dot{
+		out:   out,
+		err:   io.Discard,
+		label: template.Must(template.New("label").Parse("{{.ID}}")),
+	}
+}
+
+// assertWellFormedDotStrings walks every "..." DOT string literal in s and
+// fails the test if a quote is left unescaped, which is what a real
+// Graphviz parser would choke on.
+func assertWellFormedDotStrings(t *testing.T, s string) {
+	t.Helper()
+	inString := false
+	escaped := false
+	for i, r := range s {
+		if !inString {
+			if r == '"' {
+				inString = true
+			}
+			continue
+		}
+		if escaped {
+			escaped = false
+			continue
+		}
+		switch r {
+		case '\\':
+			escaped = true
+		case '"':
+			inString = false
+		case '\n':
+			t.Fatalf("unescaped newline inside DOT string at byte %d:\n%s", i, s)
+		}
+	}
+	if inString {
+		t.Fatalf("unterminated DOT string:\n%s", s)
+	}
+}
+
+func TestWriteRegularEscapesLabelsRefsAndTooltips(t *testing.T) {
+	tricky := `weird/pkg "quoted" \ "path`
+	nodeA := &pkggraph.Node{ID: tricky, PkgPath: tricky}
+	nodeB := &pkggraph.Node{ID: "plain/pkg", PkgPath: "plain/pkg"}
+	nodeA.ImportsNodes = []*pkggraph.Node{nodeB}
+
+	graph := &pkggraph.Graph{Sorted: []*pkggraph.Node{nodeA, nodeB}}
+
+	var out bytes.Buffer
+	ctx := newTestDot(&out)
+	ctx.docs = `docs/"base"/`
+
+	if err := ctx.WriteRegular(graph); err != nil {
+		t.Fatalf("WriteRegular: %v", err)
+	}
+
+	dot := out.String()
+	assertWellFormedDotStrings(t, dot)
+
+	if !strings.Contains(dot, escapeDot(tricky)) {
+		t.Errorf("expected escaped package path in output:\n%s", dot)
+	}
+	if strings.Contains(dot, `"`+tricky+`"`) {
+		t.Errorf("found unescaped tricky path in output:\n%s", dot)
+	}
+}
+
+func TestWriteDirClusterEscapesDisplayNameAndLabels(t *testing.T) {
+	root := pkgtree.NewDirCluster("", nil, 0)
+	child := root.GetOrCreateChild(`weird "dir"`, 1)
+	child.AddPackage(&pkgtree.Package{
+		GraphNode: &pkggraph.Node{ID: `pkg\"name`, PkgPath: `pkg\"name`},
+	})
+
+	var out bytes.Buffer
+	ctx := newTestDot(&out)
+
+	ctx.writeDirCluster(child, "    ", nil)
+
+	dot := out.String()
+	assertWellFormedDotStrings(t, dot)
+
+	if !strings.Contains(dot, escapeDot(`weird "dir"`)) {
+		t.Errorf("expected escaped display name in output:\n%s", dot)
+	}
+	if !strings.Contains(dot, escapeDot(`pkg\"name`)) {
+		t.Errorf("expected escaped package label in output:\n%s", dot)
+	}
+}
+
+func TestEscapeDotPreservesModuleReplaceNewlineAsLeftJustify(t *testing.T) {
+	label := "example.com/mod =>\n" + `replace/"path"`
+	escaped := escapeDot(label)
+	if !strings.Contains(escaped, `=>\l`) {
+		t.Errorf("expected module replace newline to become \\l, got %q", escaped)
+	}
+	if strings.Contains(escaped, "\n") {
+		t.Errorf("escaped label must not contain a raw newline: %q", escaped)
+	}
+}
+
+func TestWriteLegendDedupsDepthBands(t *testing.T) {
+	nodeA := &pkggraph.Node{ID: "a", PkgPath: "a"}
+	graph := &pkggraph.Graph{Sorted: []*pkggraph.Node{nodeA}}
+
+	var out bytes.Buffer
+	ctx := newTestDot(&out)
+	ctx.legend = true
+	ctx.clusterColors = true
+
+	ctx.writeLegend(graph, []int{1, 1, 1, 2, 2})
+
+	dot := out.String()
+	assertWellFormedDotStrings(t, dot)
+
+	if n := strings.Count(dot, `cluster depth 1:`); n != 1 {
+		t.Errorf("expected exactly one depth-1 legend line, got %d:\n%s", n, dot)
+	}
+	if n := strings.Count(dot, `cluster depth 2:`); n != 1 {
+		t.Errorf("expected exactly one depth-2 legend line, got %d:\n%s", n, dot)
+	}
+}
+
+func TestCollectDirClusterDepthsSkipsCollapsedSubtrees(t *testing.T) {
+	root := pkgtree.NewDirCluster("", nil, 0)
+	plain := root.GetOrCreateChild("plain", 1)
+	plain.AddPackage(&pkgtree.Package{GraphNode: &pkggraph.Node{ID: "plain/pkg", PkgPath: "plain/pkg"}})
+
+	collapsed := root.GetOrCreateChild("collapsed", 1)
+	collapsed.AddPackage(&pkgtree.Package{GraphNode: &pkggraph.Node{ID: "fmt", PkgPath: "fmt"}})
+	nested := collapsed.GetOrCreateChild("nested", 2)
+	nested.AddPackage(&pkgtree.Package{GraphNode: &pkggraph.Node{ID: "strings", PkgPath: "strings"}})
+
+	// writeDirCluster treats any DirCluster with Summary set as a single
+	// rectangle node and never recurses into it or writes its depth color,
+	// so mark "collapsed" the way CollapseFrontier would.
+	collapsed.Summary = &pkgtree.CollapseSummary{Path: collapsed.Path, Count: 2}
+
+	var depths []int
+	for _, child := range root.Children {
+		collectDirClusterDepths(child, &depths)
+	}
+
+	if len(depths) != 1 || depths[0] != plain.Depth-1 {
+		t.Errorf("expected only the uncollapsed child's depth to be collected, got %+v", depths)
+	}
+}
+
+// cyclicGraph returns a 3-node graph where a and b import each other (a
+// nontrivial SCC) and b also imports c, which is not part of any cycle.
+func cyclicGraph() *pkggraph.Graph {
+	a := &pkggraph.Node{ID: "cycle/a", PkgPath: "cycle/a"}
+	b := &pkggraph.Node{ID: "cycle/b", PkgPath: "cycle/b"}
+	c := &pkggraph.Node{ID: "cycle/c", PkgPath: "cycle/c"}
+	a.ImportsNodes = []*pkggraph.Node{b}
+	b.ImportsNodes = []*pkggraph.Node{a, c}
+	return &pkggraph.Graph{Sorted: []*pkggraph.Node{a, b, c}}
+}
+
+func TestWriteRegularHighlightsCycles(t *testing.T) {
+	var out bytes.Buffer
+	ctx := newTestDot(&out)
+	ctx.highlightCycles = true
+
+	if err := ctx.WriteRegular(cyclicGraph()); err != nil {
+		t.Fatalf("WriteRegular: %v", err)
+	}
+
+	dot := out.String()
+	assertWellFormedDotStrings(t, dot)
+
+	if !strings.Contains(dot, `cluster_scc_0`) {
+		t.Errorf("expected a cluster_scc_0 subgraph around the cycle:\n%s", dot)
+	}
+	if !strings.Contains(dot, `cycle/a -> cycle/b [color="red" penwidth=3]`) {
+		t.Errorf("expected the cycle edge to be colored red:\n%s", dot)
+	}
+	if strings.Contains(dot, `cycle/b -> cycle/c [color="red" penwidth=3]`) {
+		t.Errorf("non-cycle edge should not be colored red:\n%s", dot)
+	}
+}
+
+func TestWriteRegularCondensesCycles(t *testing.T) {
+	var out bytes.Buffer
+	ctx := newTestDot(&out)
+	ctx.condenseCycles = true
+
+	if err := ctx.WriteRegular(cyclicGraph()); err != nil {
+		t.Fatalf("WriteRegular: %v", err)
+	}
+
+	dot := out.String()
+	assertWellFormedDotStrings(t, dot)
+
+	if !strings.Contains(dot, sccNodeID(0)) {
+		t.Errorf("expected a condensed scc_0 node:\n%s", dot)
+	}
+	if strings.Contains(dot, "cycle/a [") || strings.Contains(dot, "cycle/b [") {
+		t.Errorf("condensed cycle members should not also appear as plain nodes:\n%s", dot)
+	}
+	if !strings.Contains(dot, sccNodeID(0)+" -> ") {
+		t.Errorf("expected an edge from the condensed node to the non-cycle package:\n%s", dot)
+	}
+}
@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Format identifies an output backend for a Writer.
+type Format int
+
+const (
+	FormatDot Format = iota
+	FormatMermaid
+	FormatGraphML
+	FormatCytoscape
+	FormatJSON
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatDot:
+		return "dot"
+	case FormatMermaid:
+		return "mermaid"
+	case FormatGraphML:
+		return "graphml"
+	case FormatCytoscape:
+		return "cytoscape"
+	case FormatJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+// formatsByName maps both the --format flag values and file extensions to
+// a Format. Extensions are checked without their leading dot.
+var formatsByName = map[string]Format{
+	"dot": FormatDot,
+
+	"mermaid": FormatMermaid,
+	"mmd":     FormatMermaid,
+
+	"graphml": FormatGraphML,
+
+	"cytoscape": FormatCytoscape,
+	"cyjson":    FormatCytoscape,
+
+	"json": FormatJSON,
+}
+
+// ParseFormat looks up a Format by its --format flag value or file
+// extension (with or without the leading dot).
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "."))
+	f, ok := formatsByName[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown format %q", name)
+	}
+	return f, nil
+}
+
+// WriterFor returns the Writer for formatFlag, or, when formatFlag is
+// empty, the Writer implied by path's extension. path may be empty when
+// writing to stdout, in which case formatFlag must be set.
+//
+// This tree has no cmd/goda, so nothing here wires formatFlag up to an
+// actual `goda graph --format` CLI flag -- WriterFor is the backend
+// dispatch such a flag would call into, not the flag itself.
+
+func WriterFor(out, err io.Writer, path, formatFlag string, opts Options) (Writer, error) {
+	name := formatFlag
+	if name == "" {
+		ext := filepath.Ext(path)
+		if ext == "" {
+			return nil, fmt.Errorf("unable to detect format: specify --format or use a recognized file extension")
+		}
+		name = ext
+	}
+
+	format, err2 := ParseFormat(name)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	if format != FormatDot {
+		if unsupported := dotOnlyOptions(opts); unsupported != "" {
+			return nil, fmt.Errorf("%s is only supported with --format=dot", unsupported)
+		}
+	}
+
+	switch format {
+	case FormatDot:
+		return NewDot(out, err, opts), nil
+	case FormatMermaid:
+		return NewMermaid(out, err, opts), nil
+	case FormatGraphML:
+		return NewGraphML(out, err, opts), nil
+	case FormatCytoscape:
+		return NewCytoscape(out, err, opts), nil
+	case FormatJSON:
+		return NewJSON(out, err, opts), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", name)
+	}
+}
+
+// dotOnlyOptions reports the flag name of the first DOT-specific option set
+// in opts that the non-DOT backends don't implement (--legend, --collapse*,
+// --highlight-cycles, --condense-cycles), or "" if none are set. Without
+// this check, picking a non-DOT --format silently drops those options
+// instead of erroring.
+func dotOnlyOptions(opts Options) string {
+	switch {
+	case opts.Legend:
+		return "--legend"
+	case opts.Collapse != nil:
+		return "--collapse"
+	case opts.HighlightCycles:
+		return "--highlight-cycles"
+	case opts.CondenseCycles:
+		return "--condense-cycles"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"io"
+	"testing"
+
+	"github.com/loov/goda/internal/pkgtree"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		want Format
+	}{
+		{"dot", FormatDot},
+		{".dot", FormatDot},
+		{"mermaid", FormatMermaid},
+		{"mmd", FormatMermaid},
+		{"graphml", FormatGraphML},
+		{"cytoscape", FormatCytoscape},
+		{"cyjson", FormatCytoscape},
+		{"JSON", FormatJSON},
+	}
+	for _, test := range tests {
+		got, err := ParseFormat(test.name)
+		if err != nil {
+			t.Errorf("ParseFormat(%q): %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseFormat(%q) = %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	if _, err := ParseFormat("bogus"); err == nil {
+		t.Errorf("ParseFormat(\"bogus\") should have failed")
+	}
+}
+
+func TestWriterForRejectsDotOnlyOptionsOnOtherFormats(t *testing.T) {
+	dotOnly := []Options{
+		{Legend: true},
+		{Collapse: pkgtree.SmallerThan(1)},
+		{HighlightCycles: true},
+		{CondenseCycles: true},
+	}
+
+	for _, opts := range dotOnly {
+		if _, err := WriterFor(io.Discard, io.Discard, "", "mermaid", opts); err == nil {
+			t.Errorf("WriterFor(%+v, format=mermaid) should have rejected a DOT-only option", opts)
+		}
+		if _, err := WriterFor(io.Discard, io.Discard, "", "dot", opts); err != nil {
+			t.Errorf("WriterFor(%+v, format=dot) should accept its own options: %v", opts, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package graph
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/loov/goda/internal/pkggraph"
+	"github.com/loov/goda/internal/pkgtree"
+)
+
+// GraphML renders a graph as GraphML, using yEd-style nested <graph>
+// elements inside a <node> to represent clusters.
+type GraphML struct {
+	out io.Writer
+	err io.Writer
+
+	opts Options
+}
+
+// NewGraphML creates a GraphML Writer.
+func NewGraphML(out, err io.Writer, opts Options) *GraphML {
+	return &GraphML{out: out, err: err, opts: opts}
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func (ctx *GraphML) label(p *pkggraph.Node) string {
+	var labelText strings.Builder
+	if err := ctx.opts.Label.Execute(&labelText, p); err != nil {
+		fmt.Fprintf(ctx.err, "template error: %v\n", err)
+	}
+	return xmlEscape(labelText.String())
+}
+
+func (ctx *GraphML) Write(graph *pkggraph.Graph) error {
+	fmt.Fprintf(ctx.out, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(ctx.out, "<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+	fmt.Fprintf(ctx.out, "  <key id=\"label\" for=\"node\" attr.name=\"label\" attr.type=\"string\"/>\n")
+	fmt.Fprintf(ctx.out, "  <key id=\"href\" for=\"node\" attr.name=\"href\" attr.type=\"string\"/>\n")
+	fmt.Fprintf(ctx.out, "  <graph id=\"G\" edgedefault=\"directed\">\n")
+
+	var err error
+	if ctx.opts.Clusters {
+		if ctx.opts.ClusterByDir {
+			err = ctx.writeDirectoryClusters(graph)
+		} else {
+			err = ctx.writeClusters(graph)
+		}
+	} else {
+		err = ctx.writeRegular(graph)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(ctx.out, "  </graph>\n")
+	fmt.Fprintf(ctx.out, "</graphml>\n")
+	return nil
+}
+
+func (ctx *GraphML) writeNode(indent, id string, n *pkggraph.Node) {
+	fmt.Fprintf(ctx.out, "%s<node id=%q>\n", indent, id)
+	fmt.Fprintf(ctx.out, "%s  <data key=\"label\">%s</data>\n", indent, ctx.label(n))
+	fmt.Fprintf(ctx.out, "%s  <data key=\"href\">%s</data>\n", indent, xmlEscape(ctx.opts.Docs+n.ID))
+	fmt.Fprintf(ctx.out, "%s</node>\n", indent)
+}
+
+func (ctx *GraphML) writeEdges(indent string, graph *pkggraph.Graph) {
+	n := 0
+	for _, src := range graph.Sorted {
+		for _, dst := range src.ImportsNodes {
+			fmt.Fprintf(ctx.out, "%s<edge id=\"e%d\" source=%q target=%q/>\n", indent, n, safeID(src.ID), safeID(dst.ID))
+			n++
+		}
+	}
+}
+
+func (ctx *GraphML) writeRegular(graph *pkggraph.Graph) error {
+	for _, n := range graph.Sorted {
+		ctx.writeNode("    ", safeID(n.ID), n)
+	}
+	ctx.writeEdges("    ", graph)
+	return nil
+}
+
+// writeClusters emits repos and modules as nested <graph> elements,
+// mirroring Dot.WriteClusters's repo/module/package subgraph nesting.
+func (ctx *GraphML) writeClusters(graph *pkggraph.Graph) error {
+	root, err := pkgtree.From(graph)
+	if err != nil {
+		return fmt.Errorf("failed to construct cluster tree: %v", err)
+	}
+
+	var visit func(tn pkgtree.Node, indent string)
+	visit = func(tn pkgtree.Node, indent string) {
+		switch tn := tn.(type) {
+		case *pkgtree.Repo:
+			if tn.SameAsOnlyModule() {
+				tn.VisitChildren(func(c pkgtree.Node) { visit(c, indent) })
+				return
+			}
+			id := safeID("repo:" + tn.Path())
+			fmt.Fprintf(ctx.out, "%s<node id=%q>\n", indent, id)
+			fmt.Fprintf(ctx.out, "%s  <data key=\"label\">%s</data>\n", indent, xmlEscape(tn.Path()))
+			fmt.Fprintf(ctx.out, "%s  <graph id=%q edgedefault=\"directed\">\n", indent, id+":")
+			tn.VisitChildren(func(c pkgtree.Node) { visit(c, indent+"    ") })
+			fmt.Fprintf(ctx.out, "%s  </graph>\n", indent)
+			fmt.Fprintf(ctx.out, "%s</node>\n", indent)
+			return
+
+		case *pkgtree.Module:
+			id := safeID("mod:" + tn.Path())
+			fmt.Fprintf(ctx.out, "%s<node id=%q>\n", indent, id)
+			fmt.Fprintf(ctx.out, "%s  <data key=\"label\">%s</data>\n", indent, xmlEscape(moduleLabel(tn)))
+			fmt.Fprintf(ctx.out, "%s  <graph id=%q edgedefault=\"directed\">\n", indent, id+":")
+			tn.VisitChildren(func(c pkgtree.Node) { visit(c, indent+"    ") })
+			fmt.Fprintf(ctx.out, "%s  </graph>\n", indent)
+			fmt.Fprintf(ctx.out, "%s</node>\n", indent)
+			return
+
+		case *pkgtree.Package:
+			ctx.writeNode(indent, safeID(tn.GraphNode.ID), tn.GraphNode)
+			tn.VisitChildren(func(c pkgtree.Node) { visit(c, indent) })
+			return
+		}
+	}
+	root.VisitChildren(func(c pkgtree.Node) { visit(c, "    ") })
+
+	ctx.writeEdges("    ", graph)
+	return nil
+}
+
+// writeDirectoryClusters emits pkgtree.DirCluster nodes as nested <graph>
+// elements, recursing the same way Dot.writeDirCluster does.
+func (ctx *GraphML) writeDirectoryClusters(graph *pkggraph.Graph) error {
+	root, err := pkgtree.From(graph)
+	if err != nil {
+		return fmt.Errorf("failed to construct cluster tree: %v", err)
+	}
+
+	basePackage := pkgtree.GetBasePackage(graph)
+	dirRoot := pkgtree.ClusterByDirectory(root, basePackage, ctx.opts.ClusterDepth)
+
+	for _, pkg := range dirRoot.Packages {
+		ctx.writeNode("    ", safeID(pkg.GraphNode.ID), pkg.GraphNode)
+	}
+	for _, child := range dirRoot.Children {
+		ctx.writeDirCluster(child, "    ")
+	}
+
+	ctx.writeEdges("    ", graph)
+	return nil
+}
+
+func (ctx *GraphML) writeDirCluster(dc *pkgtree.DirCluster, indent string) {
+	displayName := dc.Path
+	if idx := strings.LastIndex(dc.Path, "/"); idx >= 0 {
+		displayName = dc.Path[idx+1:]
+	}
+
+	id := safeID("dir:" + dc.Path)
+	fmt.Fprintf(ctx.out, "%s<node id=%q>\n", indent, id)
+	fmt.Fprintf(ctx.out, "%s  <data key=\"label\">%s</data>\n", indent, xmlEscape(displayName))
+	fmt.Fprintf(ctx.out, "%s  <graph id=%q edgedefault=\"directed\">\n", indent, id+":")
+	for _, pkg := range dc.Packages {
+		ctx.writeNode(indent+"    ", safeID(pkg.GraphNode.ID), pkg.GraphNode)
+	}
+	for _, child := range dc.Children {
+		ctx.writeDirCluster(child, indent+"    ")
+	}
+	fmt.Fprintf(ctx.out, "%s  </graph>\n", indent)
+	fmt.Fprintf(ctx.out, "%s</node>\n", indent)
+}
@@ -0,0 +1,14 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// safeID derives a syntax-safe node identifier from a package path, for
+// output formats (Mermaid, GraphML) whose node id tokens can't contain
+// slashes, dots, or other characters package paths routinely have.
+func safeID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return "n" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/loov/goda/internal/pkggraph"
+	"github.com/loov/goda/internal/pkgtree"
+)
+
+// JSON renders a graph as plain JSON: a flat node/edge list plus, when
+// clustering is enabled, a cluster tree referencing those nodes by ID.
+type JSON struct {
+	out io.Writer
+	err io.Writer
+
+	opts Options
+}
+
+// NewJSON creates a plain-JSON Writer.
+func NewJSON(out, err io.Writer, opts Options) *JSON {
+	return &JSON{out: out, err: err, opts: opts}
+}
+
+type jsonNode struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Href  string `json:"href,omitempty"`
+}
+
+type jsonEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type jsonCluster struct {
+	Path     string         `json:"path"`
+	Nodes    []string       `json:"nodes,omitempty"`
+	Clusters []*jsonCluster `json:"clusters,omitempty"`
+}
+
+type jsonDoc struct {
+	Nodes    []jsonNode     `json:"nodes"`
+	Edges    []jsonEdge     `json:"edges"`
+	Clusters []*jsonCluster `json:"clusters,omitempty"`
+}
+
+func (ctx *JSON) label(n *pkggraph.Node) string {
+	var labelText strings.Builder
+	if err := ctx.opts.Label.Execute(&labelText, n); err != nil {
+		fmt.Fprintf(ctx.err, "template error: %v\n", err)
+	}
+	return labelText.String()
+}
+
+func (ctx *JSON) Write(graph *pkggraph.Graph) error {
+	doc := jsonDoc{}
+
+	for _, n := range graph.Sorted {
+		doc.Nodes = append(doc.Nodes, jsonNode{ID: n.ID, Label: ctx.label(n), Href: ctx.opts.Docs + n.ID})
+	}
+	for _, src := range graph.Sorted {
+		for _, dst := range src.ImportsNodes {
+			doc.Edges = append(doc.Edges, jsonEdge{From: src.ID, To: dst.ID})
+		}
+	}
+
+	if ctx.opts.Clusters {
+		var err error
+		if ctx.opts.ClusterByDir {
+			doc.Clusters, err = ctx.directoryClusterTree(graph)
+		} else {
+			doc.Clusters, err = ctx.clusterTree(graph)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	enc := json.NewEncoder(ctx.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func (ctx *JSON) directoryClusterTree(graph *pkggraph.Graph) ([]*jsonCluster, error) {
+	root, err := pkgtree.From(graph)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct cluster tree: %v", err)
+	}
+
+	basePackage := pkgtree.GetBasePackage(graph)
+	dirRoot := pkgtree.ClusterByDirectory(root, basePackage, ctx.opts.ClusterDepth)
+
+	var convert func(dc *pkgtree.DirCluster) *jsonCluster
+	convert = func(dc *pkgtree.DirCluster) *jsonCluster {
+		c := &jsonCluster{Path: dc.Path}
+		for _, pkg := range dc.Packages {
+			c.Nodes = append(c.Nodes, pkg.GraphNode.ID)
+		}
+		for _, child := range dc.Children {
+			c.Clusters = append(c.Clusters, convert(child))
+		}
+		return c
+	}
+
+	var clusters []*jsonCluster
+	for _, pkg := range dirRoot.Packages {
+		clusters = append(clusters, &jsonCluster{Path: "", Nodes: []string{pkg.GraphNode.ID}})
+	}
+	for _, child := range dirRoot.Children {
+		clusters = append(clusters, convert(child))
+	}
+	return clusters, nil
+}
+
+func (ctx *JSON) clusterTree(graph *pkggraph.Graph) ([]*jsonCluster, error) {
+	root, err := pkgtree.From(graph)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct cluster tree: %v", err)
+	}
+
+	var clusters []*jsonCluster
+	var visit func(tn pkgtree.Node) *jsonCluster
+	visit = func(tn pkgtree.Node) *jsonCluster {
+		switch tn := tn.(type) {
+		case *pkgtree.Repo:
+			c := &jsonCluster{Path: tn.Path()}
+			tn.VisitChildren(func(child pkgtree.Node) {
+				if cc := visit(child); cc != nil {
+					c.Clusters = append(c.Clusters, cc)
+				}
+			})
+			return c
+
+		case *pkgtree.Module:
+			c := &jsonCluster{Path: tn.Path()}
+			tn.VisitChildren(func(child pkgtree.Node) {
+				if cc := visit(child); cc != nil {
+					c.Clusters = append(c.Clusters, cc)
+				}
+			})
+			return c
+
+		case *pkgtree.Package:
+			c := &jsonCluster{Path: tn.Path(), Nodes: []string{tn.GraphNode.ID}}
+			tn.VisitChildren(func(child pkgtree.Node) {
+				if cc := visit(child); cc != nil {
+					c.Clusters = append(c.Clusters, cc)
+				}
+			})
+			return c
+		}
+		return nil
+	}
+	root.VisitChildren(func(tn pkgtree.Node) {
+		if c := visit(tn); c != nil {
+			clusters = append(clusters, c)
+		}
+	})
+
+	return clusters, nil
+}
@@ -0,0 +1,176 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/loov/goda/internal/pkggraph"
+	"github.com/loov/goda/internal/pkgtree"
+)
+
+// Mermaid renders a graph as a Mermaid `flowchart LR` diagram.
+type Mermaid struct {
+	out io.Writer
+	err io.Writer
+
+	opts Options
+}
+
+// NewMermaid creates a Mermaid Writer.
+func NewMermaid(out, err io.Writer, opts Options) *Mermaid {
+	return &Mermaid{out: out, err: err, opts: opts}
+}
+
+// mermaidEscape escapes s for use inside a Mermaid `["..."]` node or edge
+// label, where literal quotes must be written as the `#quot;` HTML entity
+// and newlines would otherwise terminate the diagram line.
+func mermaidEscape(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "\n", "<br/>")
+	return s
+}
+
+func (ctx *Mermaid) label(p *pkggraph.Node) string {
+	var labelText strings.Builder
+	if err := ctx.opts.Label.Execute(&labelText, p); err != nil {
+		fmt.Fprintf(ctx.err, "template error: %v\n", err)
+	}
+	return mermaidEscape(labelText.String())
+}
+
+func (ctx *Mermaid) Write(graph *pkggraph.Graph) error {
+	if ctx.opts.Clusters {
+		if ctx.opts.ClusterByDir {
+			return ctx.writeDirectoryClusters(graph)
+		}
+		return ctx.writeClusters(graph)
+	}
+	return ctx.writeRegular(graph)
+}
+
+func (ctx *Mermaid) writeRegular(graph *pkggraph.Graph) error {
+	fmt.Fprintf(ctx.out, "flowchart LR\n")
+
+	for _, n := range graph.Sorted {
+		fmt.Fprintf(ctx.out, "    %s[%q]\n", safeID(n.ID), ctx.label(n))
+	}
+	for _, src := range graph.Sorted {
+		for _, dst := range src.ImportsNodes {
+			fmt.Fprintf(ctx.out, "    %s --> %s\n", safeID(src.ID), safeID(dst.ID))
+		}
+	}
+
+	return nil
+}
+
+// writeClusters mirrors Dot.WriteClusters: repos and modules become
+// nested `subgraph` blocks, and an edge into a cluster-root package
+// targets the subgraph id so it renders like DOT's `lhead`.
+func (ctx *Mermaid) writeClusters(graph *pkggraph.Graph) error {
+	root, err := pkgtree.From(graph)
+	if err != nil {
+		return fmt.Errorf("failed to construct cluster tree: %v", err)
+	}
+	lookup := root.LookupTable()
+	isCluster := map[*pkggraph.Node]bool{}
+	clusterSubgraph := map[*pkggraph.Node]string{}
+
+	fmt.Fprintf(ctx.out, "flowchart LR\n")
+
+	var visit func(tn pkgtree.Node)
+	visit = func(tn pkgtree.Node) {
+		switch tn := tn.(type) {
+		case *pkgtree.Repo:
+			if tn.SameAsOnlyModule() {
+				break
+			}
+			fmt.Fprintf(ctx.out, "    subgraph %s[%q]\n", safeID("repo:"+tn.Path()), tn.Path())
+			defer fmt.Fprintf(ctx.out, "    end\n")
+
+		case *pkgtree.Module:
+			fmt.Fprintf(ctx.out, "    subgraph %s[%q]\n", safeID("mod:"+tn.Path()), mermaidEscape(ctx.moduleLabel(tn)))
+			defer fmt.Fprintf(ctx.out, "    end\n")
+
+		case *pkgtree.Package:
+			gn := tn.GraphNode
+			if tn.Path() == tn.Parent.Path() {
+				isCluster[gn] = true
+				id := safeID("pkgcluster:" + tn.Path())
+				clusterSubgraph[gn] = id
+				fmt.Fprintf(ctx.out, "    subgraph %s[%q]\n", id, tn.Path())
+				fmt.Fprintf(ctx.out, "        %s[\" \"]\n", safeID(gn.ID))
+				fmt.Fprintf(ctx.out, "    end\n")
+			} else {
+				fmt.Fprintf(ctx.out, "    %s[%q]\n", safeID(gn.ID), ctx.label(gn))
+			}
+		}
+
+		tn.VisitChildren(visit)
+	}
+	root.VisitChildren(visit)
+
+	for _, src := range graph.Sorted {
+		srctree := lookup[src]
+		for _, dst := range src.ImportsNodes {
+			dstTree := lookup[dst]
+			if isCluster[dst] && srctree.Parent != dstTree {
+				fmt.Fprintf(ctx.out, "    %s --> %s\n", safeID(src.ID), clusterSubgraph[dst])
+			} else {
+				fmt.Fprintf(ctx.out, "    %s --> %s\n", safeID(src.ID), safeID(dst.ID))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ctx *Mermaid) moduleLabel(mod *pkgtree.Module) string {
+	return moduleLabel(mod)
+}
+
+// writeDirectoryClusters mirrors Dot.WriteDirectoryClusters: each
+// pkgtree.DirCluster becomes a nested `subgraph` block via recursion.
+func (ctx *Mermaid) writeDirectoryClusters(graph *pkggraph.Graph) error {
+	root, err := pkgtree.From(graph)
+	if err != nil {
+		return fmt.Errorf("failed to construct cluster tree: %v", err)
+	}
+
+	basePackage := pkgtree.GetBasePackage(graph)
+	dirRoot := pkgtree.ClusterByDirectory(root, basePackage, ctx.opts.ClusterDepth)
+
+	fmt.Fprintf(ctx.out, "flowchart LR\n")
+
+	for _, pkg := range dirRoot.Packages {
+		fmt.Fprintf(ctx.out, "    %s[%q]\n", safeID(pkg.GraphNode.ID), ctx.label(pkg.GraphNode))
+	}
+	for _, child := range dirRoot.Children {
+		ctx.writeDirCluster(child, "    ")
+	}
+
+	for _, src := range graph.Sorted {
+		for _, dst := range src.ImportsNodes {
+			fmt.Fprintf(ctx.out, "    %s --> %s\n", safeID(src.ID), safeID(dst.ID))
+		}
+	}
+
+	return nil
+}
+
+func (ctx *Mermaid) writeDirCluster(dc *pkgtree.DirCluster, indent string) {
+	displayName := dc.Path
+	if idx := strings.LastIndex(dc.Path, "/"); idx >= 0 {
+		displayName = dc.Path[idx+1:]
+	}
+
+	fmt.Fprintf(ctx.out, "%ssubgraph %s[%q]\n", indent, safeID("dir:"+dc.Path), mermaidEscape(displayName))
+	for _, pkg := range dc.Packages {
+		gn := pkg.GraphNode
+		fmt.Fprintf(ctx.out, "%s    %s[%q]\n", indent, safeID(gn.ID), ctx.label(gn))
+	}
+	for _, child := range dc.Children {
+		ctx.writeDirCluster(child, indent+"    ")
+	}
+	fmt.Fprintf(ctx.out, "%send\n", indent)
+}
@@ -0,0 +1,27 @@
+package graph
+
+import "github.com/loov/goda/internal/pkgtree"
+
+// moduleLabel formats a module's display label: its path and version, an
+// "(local)" suffix for a local replace target, and its replace directive (if
+// any) on a second line. Dot, Mermaid, GraphML, and Cytoscape all share this
+// so a replace directive renders identically everywhere instead of drifting
+// backend to backend; each backend still escapes the result for its own
+// syntax (escapeDot turns the "\n" into "\l", mermaidEscape into "<br/>",
+// xmlEscape into "&#xA;", and Cytoscape's JSON encoding into "\n").
+func moduleLabel(mod *pkgtree.Module) string {
+	lbl := mod.Mod.Path
+	if mod.Mod.Version != "" {
+		lbl += "@" + mod.Mod.Version
+	}
+	if mod.Local {
+		lbl += " (local)"
+	}
+	if rep := mod.Mod.Replace; rep != nil {
+		lbl += " =>\n" + rep.Path
+		if rep.Version != "" {
+			lbl += "@" + rep.Version
+		}
+	}
+	return lbl
+}
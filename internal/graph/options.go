@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"io"
+	"text/template"
+
+	"github.com/loov/goda/internal/pkggraph"
+	"github.com/loov/goda/internal/pkgtree"
+)
+
+// Options configures how a Writer renders a *pkggraph.Graph. It's shared
+// across all output backends so `--format` can switch the backend without
+// changing how clustering, coloring, or labeling is configured.
+type Options struct {
+	Docs          string
+	Clusters      bool
+	ClusterByDir  bool
+	ClusterDepth  int
+	ClusterColors bool
+	NoColor       bool
+	ShortID       bool
+	Legend        bool
+
+	// Collapse, when non-nil, replaces any directory subtree in a
+	// --cluster-by-dir render whose every package matches it with a single
+	// summary node. See pkgtree.CollapseFrontier.
+	Collapse pkgtree.CollapsePredicate
+
+	// HighlightCycles paints the edges and, where nesting allows, the
+	// members of every import cycle (SCC with more than one package) red.
+	HighlightCycles bool
+	// CondenseCycles collapses every import cycle into a single node
+	// labeled with its member count.
+	CondenseCycles bool
+
+	Label *template.Template
+}
+
+// Writer renders a *pkggraph.Graph to an output format.
+type Writer interface {
+	Write(graph *pkggraph.Graph) error
+}
+
+// NewDot creates a DOT Writer.
+func NewDot(out, err io.Writer, opts Options) *Dot {
+	return &Dot{
+		out: out,
+		err: err,
+
+		docs:          opts.Docs,
+		clusters:      opts.Clusters,
+		clusterByDir:  opts.ClusterByDir,
+		clusterDepth:  opts.ClusterDepth,
+		clusterColors: opts.ClusterColors,
+		nocolor:       opts.NoColor,
+		shortID:       opts.ShortID,
+		legend:        opts.Legend,
+		collapse:      opts.Collapse,
+
+		highlightCycles: opts.HighlightCycles,
+		condenseCycles:  opts.CondenseCycles,
+
+		label: opts.Label,
+	}
+}
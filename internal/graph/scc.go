@@ -0,0 +1,107 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/loov/goda/internal/pkggraph"
+)
+
+// tarjanSCC returns the strongly connected components of nodes (following
+// ImportsNodes edges), each as its member *pkggraph.Node slice. Components
+// are returned in Tarjan's reverse-topological order.
+func tarjanSCC(nodes []*pkggraph.Node) [][]*pkggraph.Node {
+	index := map[*pkggraph.Node]int{}
+	lowlink := map[*pkggraph.Node]int{}
+	onStack := map[*pkggraph.Node]bool{}
+	var stack []*pkggraph.Node
+	counter := 0
+	var sccs [][]*pkggraph.Node
+
+	var strongconnect func(v *pkggraph.Node)
+	strongconnect = func(v *pkggraph.Node) {
+		index[v] = counter
+		lowlink[v] = counter
+		counter++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range v.ImportsNodes {
+			if _, visited := index[w]; !visited {
+				strongconnect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] {
+				if index[w] < lowlink[v] {
+					lowlink[v] = index[w]
+				}
+			}
+		}
+
+		if lowlink[v] == index[v] {
+			var scc []*pkggraph.Node
+			for {
+				n := len(stack) - 1
+				w := stack[n]
+				stack = stack[:n]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			sccs = append(sccs, scc)
+		}
+	}
+
+	for _, n := range nodes {
+		if _, visited := index[n]; !visited {
+			strongconnect(n)
+		}
+	}
+	return sccs
+}
+
+// nontrivialSCCs maps every node that belongs to an SCC with more than one
+// member to that SCC's index (stable, 0-based, in tarjanSCC's order), and
+// returns the members of each such SCC indexed the same way.
+func nontrivialSCCs(graph *pkggraph.Graph) (sccOf map[*pkggraph.Node]int, members [][]*pkggraph.Node) {
+	sccOf = map[*pkggraph.Node]int{}
+	for _, scc := range tarjanSCC(graph.Sorted) {
+		if len(scc) < 2 {
+			continue
+		}
+		id := len(members)
+		members = append(members, scc)
+		for _, n := range scc {
+			sccOf[n] = id
+		}
+	}
+	return sccOf, members
+}
+
+// sccNodeID is the DOT node id standing in for a condensed SCC.
+func sccNodeID(id int) string {
+	return fmt.Sprintf("scc_%d", id)
+}
+
+// sccClusterName is the cluster wrapped around an SCC's members when
+// --highlight-cycles groups same-parent members visually.
+func sccClusterName(id int) string {
+	return fmt.Sprintf("cluster_scc_%d", id)
+}
+
+func sccLabel(members []*pkggraph.Node) string {
+	return fmt.Sprintf("cycle (%d pkgs)", len(members))
+}
+
+func sccTooltip(members []*pkggraph.Node) string {
+	ids := make([]string, len(members))
+	for i, n := range members {
+		ids[i] = n.ID
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, "\n")
+}
@@ -16,6 +16,14 @@ type DirCluster struct {
 	Depth        int
 	sortedChildren []string
 	childrenMap    map[string]*DirCluster
+
+	// Summary is set by CollapseFrontier on the highest DirCluster in a
+	// subtree where every descendant package matches the collapse
+	// predicate. Renderers should check Summary first and, if set, emit a
+	// single node instead of recursing into Children/Packages.
+	Summary *CollapseSummary
+
+	complete bool
 }
 
 // NewDirCluster creates a new directory cluster
@@ -184,3 +192,151 @@ func longestCommonPrefix(a, b string) string {
 	}
 	return a[:minLen]
 }
+
+// CollapsePredicate reports whether dc itself qualifies as part of a
+// collapsible subtree; CollapseFrontier separately requires every child to
+// qualify too. Build one with EveryPackage to match a per-package condition
+// on dc's own Packages (e.g. IsStdlib) or with SmallerThan for a condition
+// on the size of dc's whole subtree, and combine several with Any.
+//
+// Compiling a --collapse=std+ext style flag value into one of these (e.g.
+// via internal/pkgset/ast, or wiring up --collapse-tests) isn't done here:
+// this tree has no CLI (cmd/goda) to parse the flag in the first place, and
+// internal/pkgset/ast ships no parser/evaluator in this snapshot to compile
+// against, only its test. EveryPackage/IsStdlib/IsExt/SmallerThan/Any are
+// the composable building blocks such a compiler would target.
+type CollapsePredicate func(dc *DirCluster) bool
+
+// EveryPackage builds a CollapsePredicate that holds for a DirCluster iff
+// match holds for every one of its own Packages (vacuously true for a
+// DirCluster with none).
+func EveryPackage(match func(pkg *Package) bool) CollapsePredicate {
+	return func(dc *DirCluster) bool {
+		for _, pkg := range dc.Packages {
+			if !match(pkg) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SmallerThan builds a CollapsePredicate for --collapse-if-smaller=n: it
+// holds for a DirCluster whose entire subtree has fewer than n packages.
+func SmallerThan(n int) CollapsePredicate {
+	return func(dc *DirCluster) bool {
+		return dc.PackageCount() < n
+	}
+}
+
+// Any combines CollapsePredicates with OR, so e.g. --collapse-if-smaller
+// and an EveryPackage(IsStdlib) predicate can both be active at once.
+func Any(predicates ...CollapsePredicate) CollapsePredicate {
+	return func(dc *DirCluster) bool {
+		for _, p := range predicates {
+			if p(dc) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IsStdlib is an EveryPackage match for standard-library packages, using
+// the same "no dot in the first path element" heuristic `go list` uses.
+func IsStdlib(pkg *Package) bool {
+	id := pkg.GraphNode.ID
+	first := id
+	if idx := strings.IndexByte(id, '/'); idx >= 0 {
+		first = id[:idx]
+	}
+	return !strings.ContainsRune(first, '.')
+}
+
+// IsExt is an EveryPackage match for the complement of IsStdlib: packages
+// that come from outside the standard library (modules, vendored code).
+func IsExt(pkg *Package) bool {
+	return !IsStdlib(pkg)
+}
+
+// PackageCount returns the total number of packages in dc's subtree,
+// including dc's own Packages.
+func (dc *DirCluster) PackageCount() int {
+	n := len(dc.Packages)
+	for _, child := range dc.Children {
+		n += child.PackageCount()
+	}
+	return n
+}
+
+// CollapseSummary describes a directory subtree that CollapseFrontier
+// collapsed into a single summary node because every package underneath it
+// matched the collapse predicate.
+type CollapseSummary struct {
+	Path       string
+	Count      int
+	PackageIDs []string
+}
+
+// CollapseFrontier marks the maximal completed sub-forest of root's
+// children: a DirCluster is "complete" iff predicate holds for it AND every
+// one of its Children is complete, evaluated bottom-up. The collapse
+// frontier is the set of highest complete nodes -- once a node qualifies,
+// its descendants aren't also reported even though they're complete too,
+// since they're already covered by their ancestor's summary. Each frontier
+// node's Summary field is populated and returned in frontier order.
+func CollapseFrontier(root *DirCluster, predicate CollapsePredicate) []*DirCluster {
+	for _, child := range root.Children {
+		markComplete(child, predicate)
+	}
+
+	var frontier []*DirCluster
+	var walk func(dc *DirCluster)
+	walk = func(dc *DirCluster) {
+		if dc.complete {
+			dc.Summary = summarizeCollapsed(dc)
+			frontier = append(frontier, dc)
+			return
+		}
+		for _, child := range dc.Children {
+			walk(child)
+		}
+	}
+	for _, child := range root.Children {
+		walk(child)
+	}
+	return frontier
+}
+
+// markComplete runs the bottom-up completeness pass described by
+// CollapseFrontier and caches the result on dc.complete.
+func markComplete(dc *DirCluster, predicate CollapsePredicate) bool {
+	complete := predicate(dc)
+	for _, child := range dc.Children {
+		if !markComplete(child, predicate) {
+			complete = false
+		}
+	}
+	dc.complete = complete
+	return complete
+}
+
+// summarizeCollapsed gathers every package ID hidden underneath dc, for use
+// in the collapsed node's tooltip.
+func summarizeCollapsed(dc *DirCluster) *CollapseSummary {
+	s := &CollapseSummary{Path: dc.Path}
+
+	var collect func(n *DirCluster)
+	collect = func(n *DirCluster) {
+		for _, pkg := range n.Packages {
+			s.PackageIDs = append(s.PackageIDs, pkg.GraphNode.ID)
+		}
+		for _, child := range n.Children {
+			collect(child)
+		}
+	}
+	collect(dc)
+
+	s.Count = len(s.PackageIDs)
+	return s
+}
@@ -0,0 +1,106 @@
+package pkgtree
+
+import (
+	"testing"
+
+	"github.com/loov/goda/internal/pkggraph"
+)
+
+func pkg(id string) *Package {
+	return &Package{GraphNode: &pkggraph.Node{ID: id, PkgPath: id}}
+}
+
+func TestIsStdlibAndIsExt(t *testing.T) {
+	tests := []struct {
+		id       string
+		isStdlib bool
+	}{
+		{"fmt", true},
+		{"encoding/json", true},
+		{"github.com/loov/goda-cluster/internal/pkgtree", false},
+	}
+	for _, test := range tests {
+		p := pkg(test.id)
+		if got := IsStdlib(p); got != test.isStdlib {
+			t.Errorf("IsStdlib(%q) = %v, want %v", test.id, got, test.isStdlib)
+		}
+		if got := IsExt(p); got == test.isStdlib {
+			t.Errorf("IsExt(%q) = %v, want %v", test.id, got, !test.isStdlib)
+		}
+	}
+}
+
+func TestCollapseFrontierCollapsesCompleteSubtreeOnly(t *testing.T) {
+	// root
+	//   a (stdlib)         <- incomplete: sibling child "c" is ext
+	//     b (stdlib)        <- complete on its own
+	//     c (ext)
+	//   d (stdlib)          <- complete, along with its child
+	//     e (stdlib)
+	root := NewDirCluster("", nil, 0)
+	a := root.GetOrCreateChild("a", 1)
+	a.AddPackage(pkg("fmt"))
+	b := a.GetOrCreateChild("b", 2)
+	b.AddPackage(pkg("strings"))
+	c := a.GetOrCreateChild("c", 2)
+	c.AddPackage(pkg("github.com/loov/goda-cluster/c"))
+
+	d := root.GetOrCreateChild("d", 1)
+	d.AddPackage(pkg("io"))
+	e := d.GetOrCreateChild("e", 2)
+	e.AddPackage(pkg("sort"))
+
+	frontier := CollapseFrontier(root, EveryPackage(IsStdlib))
+
+	if len(frontier) != 2 {
+		t.Fatalf("expected two frontier nodes (b and d), got %d: %+v", len(frontier), frontier)
+	}
+	if frontier[0] != b || frontier[1] != d {
+		t.Errorf("expected frontier [b, d], got [%q, %q]", frontier[0].Path, frontier[1].Path)
+	}
+	if frontier[1].Summary.Count != 2 {
+		t.Errorf("expected d's summary to cover 2 packages, got %d", frontier[1].Summary.Count)
+	}
+	if a.complete {
+		t.Errorf("'a' subtree has a non-stdlib package and must not be marked complete")
+	}
+	if c.complete {
+		t.Errorf("'c' subtree has only an ext package and must not be marked complete")
+	}
+}
+
+func TestCollapseFrontierReportsOnlyHighestCompleteNodes(t *testing.T) {
+	root := NewDirCluster("", nil, 0)
+	parent := root.GetOrCreateChild("parent", 1)
+	parent.AddPackage(pkg("fmt"))
+	child := parent.GetOrCreateChild("child", 2)
+	child.AddPackage(pkg("strings"))
+
+	frontier := CollapseFrontier(root, EveryPackage(IsStdlib))
+
+	if len(frontier) != 1 || frontier[0] != parent {
+		t.Fatalf("expected only the parent to be reported, got %+v", frontier)
+	}
+	if child.Summary != nil {
+		t.Errorf("child's Summary should be left unset once its ancestor covers it")
+	}
+}
+
+func TestSmallerThanAndAny(t *testing.T) {
+	root := NewDirCluster("", nil, 0)
+	root.AddPackage(pkg("a"))
+	root.AddPackage(pkg("b"))
+
+	small := SmallerThan(3)
+	if !small(root) {
+		t.Errorf("SmallerThan(3) should hold for a 2-package cluster")
+	}
+	if SmallerThan(2)(root) {
+		t.Errorf("SmallerThan(2) should not hold for a 2-package cluster")
+	}
+
+	combined := Any(SmallerThan(1), SmallerThan(3))
+	if !combined(root) {
+		t.Errorf("Any should hold when at least one predicate holds")
+	}
+}